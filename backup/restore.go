@@ -0,0 +1,11 @@
+package backup
+
+import "context"
+
+// Restore copies backupFile into targetDSN using the same incremental
+// step loop as Backup, so the same binary can be used for both taking and
+// restoring backups. It is a thin, differently-named wrapper around
+// Backup: a restore is just a backup in the opposite direction.
+func Restore(ctx context.Context, backupFile, targetDSN string, opts Options) error {
+	return Backup(ctx, backupFile, targetDSN, opts)
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timestampLayout is used for both generating and parsing rotated backup
+// filenames. It's filesystem-safe (no colons) and sorts lexically in
+// timestamp order.
+const timestampLayout = "20060102T150405"
+
+// RotatedName returns the filename a rotating backup of dbName taken at t
+// should use: "dbname-YYYYMMDDTHHMMSS.db".
+func RotatedName(dbName string, t time.Time) string {
+	return fmt.Sprintf("%s-%s.db", dbName, t.Format(timestampLayout))
+}
+
+// RetentionPolicy controls which rotated backups Prune keeps.
+//
+// If Keep is greater than zero, it takes precedence and Prune simply keeps
+// the Keep most recent backups regardless of age. Otherwise Prune applies
+// a GFS (grandfather-father-son) policy, keeping the most recent backup
+// in each of the last Daily days, Weekly weeks, and Monthly months. A
+// backup that falls in more than one bucket (e.g. the latest backup
+// overall is both today's daily and this week's weekly) is only counted
+// once and is never deleted twice.
+type RetentionPolicy struct {
+	Keep int
+
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// rotatedBackup is a backup file matched against the rotation naming
+// scheme, with its embedded timestamp parsed out.
+type rotatedBackup struct {
+	path string
+	time time.Time
+}
+
+// Prune removes rotated backups of dbName in dir that fall outside
+// policy, keeping the rest. It only considers files matching the
+// "dbname-YYYYMMDDTHHMMSS.db" naming scheme produced by RotatedName;
+// anything else in dir is left untouched.
+func Prune(dir, dbName string, policy RetentionPolicy) error {
+	backups, err := listRotatedBackups(dir, dbName)
+	if err != nil {
+		return fmt.Errorf("listing backups in %q: %w", dir, err)
+	}
+
+	keep := backupsToKeep(backups, policy)
+
+	for _, b := range backups {
+		if keep[b.path] {
+			continue
+		}
+		if err := os.Remove(b.path); err != nil {
+			return fmt.Errorf("pruning %q: %w", b.path, err)
+		}
+	}
+
+	return nil
+}
+
+func listRotatedBackups(dir, dbName string) ([]rotatedBackup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := dbName + "-"
+	var backups []rotatedBackup
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".db")
+		t, err := time.Parse(timestampLayout, ts)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, rotatedBackup{path: filepath.Join(dir, name), time: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+	return backups, nil
+}
+
+// backupsToKeep applies policy to backups (sorted newest-first) and
+// returns the set of paths to retain.
+func backupsToKeep(backups []rotatedBackup, policy RetentionPolicy) map[string]bool {
+	keep := map[string]bool{}
+
+	if policy.Keep > 0 {
+		for i, b := range backups {
+			if i >= policy.Keep {
+				break
+			}
+			keep[b.path] = true
+		}
+		return keep
+	}
+
+	keepLatestPerBucket(backups, policy.Daily, keep, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepLatestPerBucket(backups, policy.Weekly, keep, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	})
+	keepLatestPerBucket(backups, policy.Monthly, keep, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	return keep
+}
+
+// keepLatestPerBucket walks backups (already sorted newest-first) and
+// marks the first (i.e. latest) backup seen in each of the maxBuckets
+// most recent distinct buckets, as determined by bucketOf.
+func keepLatestPerBucket(backups []rotatedBackup, maxBuckets int, keep map[string]bool, bucketOf func(time.Time) string) {
+	if maxBuckets <= 0 {
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, b := range backups {
+		if len(seen) >= maxBuckets {
+			return
+		}
+		bucket := bucketOf(b.time)
+		if seen[bucket] {
+			continue
+		}
+		seen[bucket] = true
+		keep[b.path] = true
+	}
+}
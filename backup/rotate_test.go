@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, layout, value string) time.Time {
+	t.Helper()
+	tm, err := time.Parse(layout, value)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", value, err)
+	}
+	return tm
+}
+
+func TestBackupsToKeep(t *testing.T) {
+	// backups is newest-first, as listRotatedBackups returns it.
+	backups := func(t *testing.T, stamps ...string) []rotatedBackup {
+		var bs []rotatedBackup
+		for _, s := range stamps {
+			bs = append(bs, rotatedBackup{
+				path: s,
+				time: mustParse(t, "2006-01-02T15:04:05", s),
+			})
+		}
+		return bs
+	}
+
+	tests := []struct {
+		name    string
+		backups []rotatedBackup
+		policy  RetentionPolicy
+		want    []string
+	}{
+		{
+			name: "keep overrides GFS policy",
+			backups: backups(t,
+				"2026-07-27T00:00:00",
+				"2026-07-26T00:00:00",
+				"2026-07-25T00:00:00",
+			),
+			policy: RetentionPolicy{Keep: 2, Daily: 7, Weekly: 4, Monthly: 6},
+			want: []string{
+				"2026-07-27T00:00:00",
+				"2026-07-26T00:00:00",
+			},
+		},
+		{
+			name: "daily bucket keeps only the latest backup per day",
+			backups: backups(t,
+				"2026-07-27T12:00:00",
+				"2026-07-27T00:00:00",
+				"2026-07-26T00:00:00",
+			),
+			policy: RetentionPolicy{Daily: 2},
+			want: []string{
+				"2026-07-27T12:00:00",
+				"2026-07-26T00:00:00",
+			},
+		},
+		{
+			name: "weekly bucket boundary",
+			backups: backups(t,
+				// 2026-07-27 is a Monday (ISO week 31), 2026-07-20 falls
+				// in the prior ISO week.
+				"2026-07-27T00:00:00",
+				"2026-07-20T00:00:00",
+			),
+			policy: RetentionPolicy{Weekly: 1},
+			want: []string{
+				"2026-07-27T00:00:00",
+			},
+		},
+		{
+			name: "monthly bucket boundary",
+			backups: backups(t,
+				"2026-07-01T00:00:00",
+				"2026-06-30T00:00:00",
+			),
+			policy: RetentionPolicy{Monthly: 2},
+			want: []string{
+				"2026-07-01T00:00:00",
+				"2026-06-30T00:00:00",
+			},
+		},
+		{
+			name: "a backup landing in multiple buckets is kept once, not deleted twice",
+			backups: backups(t,
+				"2026-07-27T00:00:00",
+			),
+			policy: RetentionPolicy{Daily: 1, Weekly: 1, Monthly: 1},
+			want: []string{
+				"2026-07-27T00:00:00",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep := backupsToKeep(tt.backups, tt.policy)
+
+			if len(keep) != len(tt.want) {
+				t.Fatalf("backupsToKeep() kept %d backups, want %d (%v)", len(keep), len(tt.want), keep)
+			}
+			for _, path := range tt.want {
+				if !keep[path] {
+					t.Errorf("backupsToKeep() did not keep %q, want kept", path)
+				}
+			}
+		})
+	}
+}
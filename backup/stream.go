@@ -0,0 +1,42 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ToWriter backs up srcDSN the same way Backup does, but streams the
+// resulting database file to w instead of leaving it on disk. This lets
+// callers pipe a backup straight into gzip, age, a tar archive, or an
+// object-storage upload without managing an intermediate file themselves.
+//
+// Internally it still runs the backup against a temporary on-disk SQLite
+// file (the incremental backup API requires a real file handle), then
+// copies that file's bytes to w and removes it.
+func ToWriter(ctx context.Context, srcDSN string, w io.Writer, opts Options) error {
+	tmp, err := os.CreateTemp("", "sqlite-backup-*.db")
+	if err != nil {
+		return fmt.Errorf("creating temp destination: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := Backup(ctx, srcDSN, tmpPath, opts); err != nil {
+		return err
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return fmt.Errorf("opening temp destination: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("streaming backup to writer: %w", err)
+	}
+
+	return nil
+}
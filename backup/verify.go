@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Verify reopens dsn read-only and runs PRAGMA integrity_check and PRAGMA
+// foreign_key_check against it, returning an error describing the first
+// problem found. It's meant to be run against a freshly written backup
+// file to catch a corrupt or inconsistent copy before it's trusted.
+func Verify(ctx context.Context, dsn string) error {
+	// go-sqlite3 only passes URI parameters such as mode=ro through to
+	// SQLite for DSNs with the "file:" prefix; on a plain path it drops them.
+	roDSN := dsn + "?mode=ro"
+	if !strings.HasPrefix(roDSN, "file:") {
+		roDSN = "file:" + roDSN
+	}
+	db, err := sql.Open("sqlite3", roDSN)
+	if err != nil {
+		return fmt.Errorf("opening %q for verification: %w", dsn, err)
+	}
+	defer db.Close()
+
+	if err := checkPragma(ctx, db, "PRAGMA integrity_check"); err != nil {
+		return err
+	}
+	if err := checkPragma(ctx, db, "PRAGMA foreign_key_check"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkPragma runs a PRAGMA that reports problems as result rows (as
+// opposed to failing outright) and fails if any row comes back other than
+// the single "ok" row integrity_check reports on success.
+func checkPragma(ctx context.Context, db *sql.DB, pragma string) error {
+	rows, err := db.QueryContext(ctx, pragma)
+	if err != nil {
+		return fmt.Errorf("running %s: %w", pragma, err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		cols, err := rows.Columns()
+		if err != nil {
+			return fmt.Errorf("running %s: %w", pragma, err)
+		}
+
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("running %s: %w", pragma, err)
+		}
+
+		if len(vals) == 1 {
+			if s, ok := vals[0].(string); ok && s == "ok" {
+				continue
+			}
+		}
+		problems = append(problems, fmt.Sprint(vals))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("running %s: %w", pragma, err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s reported problems: %v", pragma, problems)
+	}
+	return nil
+}
@@ -1,133 +1,94 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	sqlite "github.com/mattn/go-sqlite3"
+	"github.com/netixx/sqlite-backup/backup"
 )
 
-var sourceFile = flag.String("source", "source.db", "Source file for backup")
-var destFile = flag.String("dest", "dest.db", "Dest file for backup")
-var driverName = "sqlite3_backup"
-var timeout int64 = 10
+var sourceFile = flag.String("source", "source.db", "Source file for backup, or backup file to restore from in restore mode")
+var destFile = flag.String("dest", "dest.db", "Dest file for backup, or live target to restore into in restore mode. With -interval, a directory to write rotated backups into")
+var pageBatchSize = flag.Int("pages", 1, "Number of pages to copy per backup step")
+var stream = flag.Bool("stream", false, "Write the backup to stdout instead of -dest, e.g. for piping into gzip or a tar archive")
+var schemas = flag.String("schema", "main", "Comma-separated list of schema names to back up, e.g. \"main,attached_db\"")
+var walCheckpoint = flag.Bool("wal-checkpoint", false, "Checkpoint the source WAL into the main database file before backing it up")
+var consistentSnapshot = flag.Bool("consistent-snapshot", false, "Hold a read transaction on the source for the duration of the backup")
+var verify = flag.Bool("verify", false, "Run PRAGMA integrity_check and foreign_key_check against the destination once the backup finishes")
+var interval = flag.Duration("interval", 0, "If set, loop forever taking a fresh rotated backup into the -dest directory every interval instead of a single backup")
+var dbName = flag.String("name", "backup", "Base name for rotated backup files written under -interval, e.g. \"dbname-20060102T150405.db\"")
+var keep = flag.Int("keep", 0, "With -interval, keep only the N most recent rotated backups, overriding the -keep-daily/-keep-weekly/-keep-monthly policy")
+var keepDaily = flag.Int("keep-daily", 7, "With -interval, keep the latest rotated backup for each of the last N days")
+var keepWeekly = flag.Int("keep-weekly", 4, "With -interval, keep the latest rotated backup for each of the last N weeks")
+var keepMonthly = flag.Int("keep-monthly", 6, "With -interval, keep the latest rotated backup for each of the last N months")
 
 func main() {
+	mode := "backup"
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		mode = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
 	flag.Parse()
 
-	driverConns := []*sqlite.SQLiteConn{}
-	sql.Register(driverName, &sqlite.SQLiteDriver{
-		ConnectHook: func(conn *sqlite.SQLiteConn) error {
-			driverConns = append(driverConns, conn)
-			return nil
+	opts := backup.Options{
+		PageBatchSize:      *pageBatchSize,
+		Schemas:            strings.Split(*schemas, ","),
+		WALCheckpoint:      *walCheckpoint,
+		ConsistentSnapshot: *consistentSnapshot,
+		Verify:             *verify,
+		Progress: func(pageCount, remaining int) {
+			log.Printf("backup progress: %d/%d pages remaining", remaining, pageCount)
 		},
-	})
-
-	srcDb, err := sql.Open(driverName, *sourceFile)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	defer srcDb.Close()
-	srcDb.Ping()
-
-	dstDb, err := sql.Open(driverName, *destFile)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer dstDb.Close()
-	dstDb.Ping()
-
-	// Check the driver connections.
-	if len(driverConns) != 2 {
-		log.Fatalf("Expected 2 driver connections, but found %v.", len(driverConns))
-	}
-	srcDbDriverConn := driverConns[0]
-	if srcDbDriverConn == nil {
-		log.Fatal("The source database driver connection is nil.")
-	}
-	destDbDriverConn := driverConns[1]
-	if destDbDriverConn == nil {
-		log.Fatal("The destination database driver connection is nil.")
 	}
 
-	backup, err := destDbDriverConn.Backup("main", srcDbDriverConn, "main")
-	if err != nil {
-		log.Fatal("Error calling backup", err)
+	var err error
+	switch mode {
+	case "backup":
+		if *interval > 0 {
+			err = runRotating(context.Background(), opts)
+		} else if *stream {
+			err = backup.ToWriter(context.Background(), *sourceFile, os.Stdout, opts)
+		} else {
+			err = backup.Backup(context.Background(), *sourceFile, *destFile, opts)
+		}
+	case "restore":
+		err = backup.Restore(context.Background(), *sourceFile, *destFile, opts)
+	default:
+		log.Fatalf("unknown mode %q: expected \"backup\" or \"restore\"", mode)
 	}
-
-	isDone, err := backup.Step(0)
 	if err != nil {
-		log.Fatal("Unable to perform an initial 0-page backup step:", err)
-	}
-	if isDone {
-		log.Fatal("Backup is unexpectedly done.")
+		log.Fatalf("%s failed: %v", mode, err)
 	}
+}
 
-	// Check that the page count and remaining values are reasonable.
-	initialPageCount := backup.PageCount()
-	if initialPageCount <= 0 {
-		log.Fatalf("Unexpected initial page count value: %v", initialPageCount)
-	}
-	initialRemaining := backup.Remaining()
-	if initialRemaining <= 0 {
-		log.Fatalf("Unexpected initial remaining value: %v", initialRemaining)
-	}
-	if initialRemaining != initialPageCount {
-		log.Fatalf("Initial remaining value differs from the initial page count value; remaining: %v; page count: %v", initialRemaining, initialPageCount)
+// runRotating loops forever, writing a timestamped backup into the -dest
+// directory every -interval and pruning old backups per the -keep* flags,
+// so the binary can run as a long-lived sidecar instead of under cron.
+func runRotating(ctx context.Context, opts backup.Options) error {
+	policy := backup.RetentionPolicy{
+		Keep:    *keep,
+		Daily:   *keepDaily,
+		Weekly:  *keepWeekly,
+		Monthly: *keepMonthly,
 	}
 
-	var startTime = time.Now().Unix()
-
-	// Test backing-up using a page-by-page approach.
-	var latestRemaining = initialRemaining
 	for {
-		// Perform the backup step.
-		isDone, err = backup.Step(1)
-		if err != nil {
-			log.Fatal("Failed to perform a backup step:", err)
-		}
-
-		// The page count should remain unchanged from its initial value.
-		currentPageCount := backup.PageCount()
-		if currentPageCount != initialPageCount {
-			log.Fatalf("Current page count differs from the initial page count; initial page count: %v; current page count: %v", initialPageCount, currentPageCount)
-		}
-
-		// There should now be one less page remaining.
-		currentRemaining := backup.Remaining()
-		expectedRemaining := latestRemaining - 1
-		if currentRemaining != expectedRemaining {
-			log.Fatalf("Unexpected remaining value; expected remaining value: %v; actual remaining value: %v", expectedRemaining, currentRemaining)
-		}
-		latestRemaining = currentRemaining
-
-		if isDone {
-			break
+		dest := filepath.Join(*destFile, backup.RotatedName(*dbName, time.Now()))
+		if err := backup.Backup(ctx, *sourceFile, dest, opts); err != nil {
+			log.Printf("rotating backup failed, will retry next interval: %v", err)
+		} else if err := backup.Prune(*destFile, *dbName, policy); err != nil {
+			log.Printf("pruning old backups failed, will retry next interval: %v", err)
 		}
 
-		// Limit the runtime of the backup attempt.
-		if (time.Now().Unix() - startTime) > timeout {
-			log.Fatal("Backup is taking longer than expected.")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*interval):
 		}
 	}
-
-	// Check that the page count and remaining values are reasonable.
-	finalPageCount := backup.PageCount()
-	if finalPageCount != initialPageCount {
-		log.Fatalf("Final page count differs from the initial page count; initial page count: %v; final page count: %v", initialPageCount, finalPageCount)
-	}
-	finalRemaining := backup.Remaining()
-	if finalRemaining != 0 {
-		log.Fatalf("Unexpected remaining value: %v", finalRemaining)
-	}
-
-	// Finish the backup.
-	err = backup.Finish()
-	if err != nil {
-		log.Fatal("Failed to finish backup:", err)
-	}
-
 }